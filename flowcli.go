@@ -1,22 +1,65 @@
 package main
 
 import (
-	"bufio"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"go/ast"
-	"go/parser"
-	"go/token"
 	"log"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+
+	"hamza/binary/internal/cache"
+	"hamza/binary/internal/codegen"
+	"hamza/binary/internal/loader"
 )
 
 type Config struct {
-	DirName string `json:"dir_name"`
-	OutDir  string `json:"out_dir"`
+	// DirNames is a list of go/packages patterns to load handlers from:
+	// a plain directory ("handlers"), a relative path ("./handlers"), a
+	// wildcard ("./...", "./handlers/..."), or a fully qualified import
+	// path ("example.com/app/handlers").
+	DirNames []string `json:"dir_name"`
+	OutDir   string   `json:"out_dir"`
+	// Namespace prefixes FuncMap keys with their package name (e.g.
+	// "foo.Handler"), so handlers of the same name in different
+	// packages don't collide.
+	Namespace bool `json:"namespace"`
+}
+
+// UnmarshalJSON accepts dir_name as either a JSON array of strings (the
+// current multi-pattern form) or a single JSON string (the original,
+// single-directory form dir_name had before multi-pattern support was
+// added), so a flowconfig.json written before that change keeps working
+// unchanged.
+func (c *Config) UnmarshalJSON(data []byte) error {
+	type alias Config
+	var aux struct {
+		alias
+		DirNames json.RawMessage `json:"dir_name"`
+	}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	*c = Config(aux.alias)
+
+	if len(aux.DirNames) == 0 {
+		return nil
+	}
+
+	var multi []string
+	if err := json.Unmarshal(aux.DirNames, &multi); err == nil {
+		c.DirNames = multi
+		return nil
+	}
+
+	var single string
+	if err := json.Unmarshal(aux.DirNames, &single); err != nil {
+		return fmt.Errorf(`dir_name must be a string or an array of strings: %w`, err)
+	}
+	c.DirNames = []string{single}
+	return nil
 }
 
 var (
@@ -24,19 +67,71 @@ var (
 	ErrExtractFailure            = errors.New("critical extracting failure")
 	ErrDirectoryFailure          = errors.New("direcotry reading error")
 	ErrSchemaVerificationFailure = errors.New("function schema verification failed")
-	ErrInvalidPackage            = errors.New("invalid package name")
-	ErrInvalidMod                = errors.New("critical go.mod error")
 	ErrInvalidRequestOutputs     = errors.New("critical output creation failure")
+	ErrConfigDiscoveryFailure    = errors.New("flowconfig.json discovery failed")
 )
 
 func main() {
-	err := ProcessFunctions()
-	if err != nil {
+	if err := run(os.Args[1:]); err != nil {
 		log.Fatalf("Error: %v", err)
 	}
 	log.Println("flowcli binary executed successfully!")
 }
 
+// run dispatches to the "generate" subcommand when invoked as
+// `flowcli generate` -- the form a //go:generate directive uses -- and
+// otherwise runs the pipeline directly against the current directory's
+// flowconfig.json, preserving flowcli's original invocation.
+func run(args []string) error {
+	if len(args) > 0 && args[0] == "generate" {
+		return runGenerate()
+	}
+	return ProcessFunctions()
+}
+
+// runGenerate implements `flowcli generate`, meant to be invoked via a
+// //go:generate flowcli generate directive. go generate runs with the
+// working directory set to wherever the directive appears, which isn't
+// necessarily where flowconfig.json lives, so this walks up from the
+// current directory to find it first -- the same way cache.ModulePath
+// walks up looking for go.mod -- before running the normal pipeline.
+func runGenerate() error {
+	dir, err := findConfigDir(".")
+	if err != nil {
+		eInfo := fmt.Errorf("locating flowconfig.json: %s", err)
+		return errors.Join(ErrConfigDiscoveryFailure, eInfo, err)
+	}
+
+	if err := os.Chdir(dir); err != nil {
+		eInfo := fmt.Errorf("changing to config directory %s: %s", dir, err)
+		return errors.Join(ErrConfigDiscoveryFailure, eInfo, err)
+	}
+	return ProcessFunctions()
+}
+
+// findConfigDir walks up from start looking for flowconfig.json, the same
+// way cache.ModulePath walks up looking for go.mod.
+func findConfigDir(start string) (string, error) {
+	abs, err := filepath.Abs(start)
+	if err != nil {
+		return "", fmt.Errorf("resolving %s: %w", start, err)
+	}
+
+	for d := abs; ; {
+		if _, err := os.Stat(filepath.Join(d, "flowconfig.json")); err == nil {
+			return d, nil
+		} else if !os.IsNotExist(err) {
+			return "", fmt.Errorf("checking %s: %w", d, err)
+		}
+
+		parent := filepath.Dir(d)
+		if parent == d {
+			return "", fmt.Errorf("no flowconfig.json found above %s", start)
+		}
+		d = parent
+	}
+}
+
 func ProcessFunctions() error {
 	const configFile = "flowconfig.json"
 
@@ -47,35 +142,23 @@ func ProcessFunctions() error {
 		return errors.Join(ErrProcessFailure, eInfo, err)
 	}
 
-	// Validate input directory
-	if err := validateDir(config.DirName); err != nil {
+	// Validate input directories
+	if err := validateDir(config.DirNames); err != nil {
 		eInfo := fmt.Errorf("configuration directory error: %s", err)
 		return errors.Join(ErrDirectoryFailure, eInfo, err)
 	}
 
-	// Extract functions from the directory
-	funcMap, err := extractFunctions(config.DirName)
+	// Extract functions from every configured pattern, reusing the
+	// cached index for any literal directory that hasn't changed since
+	// the last run.
+	pkgs, err := loadPackages(config.DirNames)
 	if err != nil {
 		eInfo := fmt.Errorf("error extracting functions: %s", err)
 		return errors.Join(ErrExtractFailure, eInfo, err)
 	}
 
-	// Extract package name
-	packageName, err := getPackageName(config.DirName)
-	if err != nil {
-		eInfo := fmt.Errorf("error extracting package name: %s", err)
-		return errors.Join(ErrInvalidPackage, eInfo, err)
-	}
-
-	// Get base URL from go.mod
-	baseURL, err := getBaseURL()
-	if err != nil {
-		eInfo := fmt.Errorf("error extracting base URL: %s", err)
-		return errors.Join(ErrInvalidMod, eInfo, err)
-	}
-
 	// Generate the output file
-	if err := createOutputFile(config.OutDir, config.DirName, funcMap, baseURL+"/"+packageName); err != nil {
+	if err := createOutputFile(config.OutDir, pkgs, config.Namespace); err != nil {
 		eInfo := fmt.Errorf("error creating output file: %s", err)
 		return errors.Join(ErrInvalidRequestOutputs, eInfo, err)
 	}
@@ -98,193 +181,239 @@ func loadConfig(fileName string) (*Config, error) {
 	return &config, nil
 }
 
-// validateDir checks if a directory exists.
-func validateDir(dir string) error {
-	if _, err := os.Stat(dir); os.IsNotExist(err) {
-		return fmt.Errorf("directory '%s' does not exist", dir)
+// validateDir checks that every pattern explicitly written as a
+// filesystem path (".", "./foo", "../foo", or "/foo" -- the same
+// leading-dot-or-slash convention the go command itself uses to tell a
+// path from an import path) names a directory that exists. Bare names
+// and import-path-style patterns are left for the loader to resolve
+// and report on: go/packages may still resolve a bare name as a valid
+// import path even when no local directory of that name exists.
+func validateDir(patterns []string) error {
+	for _, pattern := range patterns {
+		if !isExplicitPath(pattern) {
+			continue
+		}
+		if _, err := os.Stat(pattern); os.IsNotExist(err) {
+			return fmt.Errorf("directory '%s' does not exist", pattern)
+		}
 	}
 	return nil
 }
 
-// extractFunctions scans the directory and maps functions that match the required signature.
-func extractFunctions(dir string) (map[string]string, error) {
-	funcMap := make(map[string]string)
-
-	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-		if !info.IsDir() && strings.HasSuffix(info.Name(), ".go") {
-			fset := token.NewFileSet()
-			node, err := parser.ParseFile(fset, path, nil, parser.AllErrors)
-			if err != nil {
-				return err
-			}
-
-			for _, decl := range node.Decls {
-				if funcDecl, ok := decl.(*ast.FuncDecl); ok {
-					if isValidFuncType(funcDecl) {
-						funcMap[funcDecl.Name.Name] = funcDecl.Name.Name
-					}
-				}
-			}
-		}
-		return nil
-	})
-
-	return funcMap, err
+func isExplicitPath(pattern string) bool {
+	return pattern == "." || strings.HasPrefix(pattern, "./") || strings.HasPrefix(pattern, "../") || strings.HasPrefix(pattern, "/")
 }
 
-func isValidFuncType(funcDecl *ast.FuncDecl) bool {
-	// Check if the function has parameters
-	if funcDecl.Type.Params == nil || len(funcDecl.Type.Params.List) != 2 {
-		return false
-	}
-
-	// Validate first parameter: *ProcessContext
-	firstParam := funcDecl.Type.Params.List[0]
-	if !isType(firstParam.Type, "*flow.ProcessContext") {
-		return false
+// normalizeDirPattern rewrites a bare directory name like "handlers" into
+// "./handlers" before it's handed to go/packages. go/packages uses the
+// same leading-dot-or-slash convention isExplicitPath checks -- a pattern
+// without it is resolved as an import path, not a relative directory --
+// so a bare name that isLiteralDir has already confirmed exists on disk
+// still needs this rewrite to be loaded as the directory it is instead of
+// looked up as a package path.
+func normalizeDirPattern(pattern string) string {
+	if isExplicitPath(pattern) {
+		return pattern
 	}
+	return "./" + pattern
+}
 
-	// Validate second parameter: []DefinedInput
-	secondParam := funcDecl.Type.Params.List[1]
-	if !isType(secondParam.Type, "[]flow.DefinedInput") {
+// isLiteralDir reports whether pattern resolves to a single, already
+// existing local directory, as opposed to a wildcard ("./...") or an
+// import path that may not exist in the local filesystem. Caching a
+// wildcard's expansion would mean re-resolving it on every run anyway,
+// which defeats the point, so only literal directories go through the
+// cache; a bare name that happens to exist as a directory (the
+// original single-directory config shape) still qualifies.
+func isLiteralDir(pattern string) bool {
+	if strings.Contains(pattern, "...") {
 		return false
 	}
+	info, err := os.Stat(pattern)
+	return err == nil && info.IsDir()
+}
 
-	return true
+// cacheCandidate is a literal-directory pattern whose cache index
+// missed, paired with the module path and file hash loadPackages
+// already computed for it so Save doesn't need to recompute them.
+type cacheCandidate struct {
+	dir        string
+	modulePath string
+	hash       string
 }
 
-// Helper function to check if the parameter matches the required type
-func isType(expr ast.Expr, expectedType string) bool {
-	switch t := expr.(type) {
-	case *ast.StarExpr: // Handle pointer types
-		if expectedType[0] == '*' {
-			return isType(t.X, expectedType[1:])
-		}
-	case *ast.ArrayType: // Handle slice types
-		if len(expectedType) > 2 && expectedType[:2] == "[]" {
-			return isType(t.Elt, expectedType[2:])
-		}
-	case *ast.Ident: // Handle identifiers
-		return t.Name == expectedType
-	case *ast.SelectorExpr: // Handle package-prefixed types
-		if sel, ok := t.X.(*ast.Ident); ok {
-			return sel.Name+"."+t.Sel.Name == expectedType
+// loadPackages resolves every pattern into one codegen.Package per
+// matched Go package. Patterns naming a literal local directory are
+// checked against the on-disk index cache first; any that hit are
+// reused without touching go/packages at all. Everything else --
+// cache misses, wildcards, and import-path patterns -- is resolved in
+// a single batched extractPackages call, so a cold cache across many
+// directories still costs one packages.Load instead of one per
+// directory. The returned slice is sorted by import path, so which
+// patterns happened to be cache-hot doesn't affect the order callers
+// see.
+func loadPackages(patterns []string) ([]codegen.Package, error) {
+	var pkgs []codegen.Package
+	var toLoad []string
+	var candidates []cacheCandidate
+
+	for _, pattern := range patterns {
+		if !isLiteralDir(pattern) {
+			toLoad = append(toLoad, pattern)
+			continue
 		}
-	}
-	return false
-}
 
-// getPackageName retrieves the package name from the directory's Go files.
-func getPackageName(dir string) (string, error) {
-	var packageName string
+		loadPattern := normalizeDirPattern(pattern)
+
+		modulePath, modErr := cache.ModulePath(pattern)
+		hash, hashErr := cache.HashDir(pattern)
+		if modErr != nil || hashErr != nil {
+			log.Printf("flowcli: cache disabled for %s: %v", pattern, errors.Join(modErr, hashErr))
+			toLoad = append(toLoad, loadPattern)
+			continue
+		}
 
-	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		entry, err := cache.Load(modulePath, pattern)
 		if err != nil {
-			return err
+			log.Printf("flowcli: ignoring unreadable cache index for %s: %v", pattern, err)
+			toLoad = append(toLoad, loadPattern)
+			continue
 		}
-		if !info.IsDir() && strings.HasSuffix(info.Name(), ".go") {
-			fset := token.NewFileSet()
-			node, err := parser.ParseFile(fset, path, nil, parser.PackageClauseOnly)
-			if err != nil {
-				return fmt.Errorf("error parsing file %s: %w", path, err)
-			}
-			packageName = node.Name.Name
-			return filepath.SkipDir
+		if entry != nil && entry.Hash == hash {
+			log.Printf("flowcli: %s unchanged since last run, reusing cached handlers", pattern)
+			pkgs = append(pkgs, entry.Package)
+			continue
 		}
-		return nil
-	})
 
-	if err != nil {
-		return "", err
+		toLoad = append(toLoad, loadPattern)
+		candidates = append(candidates, cacheCandidate{dir: pattern, modulePath: modulePath, hash: hash})
 	}
-	if packageName == "" {
-		return "", fmt.Errorf("no Go files found in directory: %s", dir)
+
+	if len(toLoad) > 0 {
+		extracted, err := extractPackages(toLoad)
+		if err != nil {
+			return nil, err
+		}
+		pkgs = append(pkgs, extracted...)
+		saveCacheCandidates(candidates, extracted)
 	}
 
-	return packageName, nil
+	if len(pkgs) == 0 {
+		return nil, fmt.Errorf("no packages found for patterns: %v", patterns)
+	}
+
+	// Cache hits are appended as they're found in the patterns loop,
+	// while cache misses only arrive afterwards in one batch, so pkgs'
+	// order here reflects which patterns happened to be cache-hot, not
+	// flowconfig.json's pattern order. Sorting by import path makes the
+	// final order -- and therefore the import aliases codegen.Render
+	// assigns same-named packages -- depend only on the package set
+	// itself, so out.go stays byte-identical across runs with no
+	// source changes, regardless of cache state.
+	sort.Slice(pkgs, func(i, j int) bool { return pkgs[i].ImportPath < pkgs[j].ImportPath })
+
+	return pkgs, nil
 }
 
-func getBaseURL() (string, error) {
-	// Get the current working directory
-	dir, err := os.Getwd()
-	if err != nil {
-		return "", fmt.Errorf("error getting current directory: %w", err)
-	}
+// saveCacheCandidates matches each cache-miss directory back to the
+// package extractPackages resolved it to (by directory, since a batched
+// load no longer keeps patterns and results in the same order) and
+// writes its index entry for the next run.
+func saveCacheCandidates(candidates []cacheCandidate, extracted []codegen.Package) {
+	for _, c := range candidates {
+		abs, err := filepath.Abs(c.dir)
+		if err != nil {
+			continue
+		}
 
-	// Check for go.mod file in the same directory
-	goModPath := filepath.Join(dir, "go.mod")
-	if _, err := os.Stat(goModPath); err != nil {
-		if os.IsNotExist(err) {
-			return "", fmt.Errorf("go.mod file not found in the current directory: %s", dir)
+		for _, pkg := range extracted {
+			pkgAbs, err := filepath.Abs(pkg.Dir)
+			if err != nil || pkgAbs != abs {
+				continue
+			}
+			if err := cache.Save(c.modulePath, c.dir, cache.Entry{Hash: c.hash, Package: pkg}); err != nil {
+				log.Printf("flowcli: failed to write cache index for %s: %v", c.dir, err)
+			}
+			break
 		}
-		return "", fmt.Errorf("error checking go.mod file: %w", err)
 	}
+}
 
-	// Open the go.mod file
-	file, err := os.Open(goModPath)
+// extractPackages loads each of the given go/packages patterns --
+// literal directories, wildcards like "./...", or fully qualified
+// import paths -- and collects, per resolved package, the functions
+// explicitly opted in with a //flow:handler doc-comment marker that
+// also match the required handler signature. Requiring the marker
+// means a package can define helper functions shaped like a handler
+// without exporting them to FuncMap by accident. Loading through the
+// loader (rather than walking the filesystem by hand) means patterns
+// are resolved against their enclosing go.mod, so this also yields
+// each package's name and fully qualified import path, which used to
+// require a separate getPackageName/getBaseURL pass.
+func extractPackages(patterns []string) ([]codegen.Package, error) {
+	ldr := loader.New("")
+	hps, err := ldr.Load(patterns...)
 	if err != nil {
-		return "", fmt.Errorf("error opening go.mod: %w", err)
+		return nil, err
 	}
-	defer file.Close()
-
-	// Scan the file for the module name
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := scanner.Text()
-		if strings.HasPrefix(line, "module ") {
-			return strings.TrimSpace(strings.TrimPrefix(line, "module")), nil
-		}
+	if len(hps) == 0 {
+		return nil, fmt.Errorf("no package found for patterns: %v", patterns)
 	}
 
-	// If no module name is found
-	return "", fmt.Errorf("module name not found in go.mod")
+	out := make([]codegen.Package, 0, len(hps))
+	for _, hp := range hps {
+		pkg := codegen.Package{Name: hp.Pkg.Name, ImportPath: hp.Pkg.PkgPath, Dir: loader.PackageDir(hp.Pkg)}
+
+		ft, err := loader.ResolveFlowTypes(hp.Pkg)
+		if err != nil {
+			// Package doesn't import flow at all, so it has no handlers
+			// to validate.
+			out = append(out, pkg)
+			continue
+		}
+
+		for _, funcDecl := range hp.Funcs {
+			marker, tagged := loader.ParseMarker(funcDecl.Doc)
+			if !tagged {
+				continue
+			}
+
+			ok, diag := loader.IsHandler(hp.Pkg, funcDecl, ft, ldr.Fset)
+			if diag != nil {
+				log.Printf("flowcli: ignoring handler candidate %s", diag)
+			}
+			if !ok {
+				continue
+			}
+
+			pkg.Handlers = append(pkg.Handlers, codegen.Handler{
+				Name:   funcDecl.Name.Name,
+				Key:    marker.Name,
+				Inputs: marker.Inputs,
+				Meta:   marker.Meta,
+			})
+		}
+		out = append(out, pkg)
+	}
+	return out, nil
 }
 
-// createOutputFile generates the out.go file in the specified output directory.
-func createOutputFile(outDir, dirName string, funcMap map[string]string, packageName string) error {
+// createOutputFile renders out.go in the specified output directory
+// from pkgs, gofmt-ing and resolving its imports before writing so the
+// generated file is always valid, reproducible Go source.
+func createOutputFile(outDir string, pkgs []codegen.Package, namespace bool) error {
 	if err := os.MkdirAll(outDir, os.ModePerm); err != nil {
 		return fmt.Errorf("failed to create output directory: %w", err)
 	}
 
 	outFilePath := filepath.Join(outDir, "out.go")
-	file, err := os.Create(outFilePath)
+	src, err := codegen.Render(outFilePath, pkgs, codegen.Options{Namespace: namespace})
 	if err != nil {
-		return fmt.Errorf("failed to create out.go: %w", err)
+		return fmt.Errorf("failed to render out.go: %w", err)
 	}
-	defer file.Close()
-
-	builder := &strings.Builder{}
-	builder.WriteString(fmt.Sprintf(`package output
 
-import (
-	"fmt"
-	"github.com/e4coder/flow"
-	"%s"
-)
-
-var FuncMap = make(map[string]flow.ProcessHandler)
-
-func Init() {
-`, packageName))
-
-	for name := range funcMap {
-		builder.WriteString(fmt.Sprintf("\tFuncMap[\"%s\"] = %s.%s\n", name, filepath.Base(dirName), name))
+	if err := os.WriteFile(outFilePath, src, 0o644); err != nil {
+		return fmt.Errorf("failed to write out.go: %w", err)
 	}
-
-	builder.WriteString(`}
-
-func GetFuncByName(name string) (flow.ProcessHandler, error) {
-	fn, ok := FuncMap[name]
-	if !ok {
-		return nil, fmt.Errorf("function %s not found", name)
-	}
-	return fn, nil
-}
-`)
-
-	_, err = file.WriteString(builder.String())
-	return err
+	return nil
 }
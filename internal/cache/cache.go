@@ -0,0 +1,199 @@
+// Package cache implements a module-aware index that lets flowcli skip
+// re-parsing and re-type-checking a handler directory when none of its
+// .go files have changed since the last run. It borrows its staleness
+// check from cmd/go/internal/modindex: rather than hashing file
+// contents, it stamps each file with its path, size and modification
+// time and compares that stamp set across runs. A cached entry is also
+// tied to the schemaVersion of the extraction/validation logic that
+// produced it, so an upgrade that changes those rules invalidates
+// existing caches even when the directory's files haven't changed.
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"golang.org/x/mod/modfile"
+
+	"hamza/binary/internal/codegen"
+)
+
+// schemaVersion identifies the extraction and validation logic a cached
+// Entry was produced by: the //flow:handler marker rules, the signature
+// checks in internal/loader, and the Entry/Package shape itself. Bump it
+// whenever any of those change, so a cache written by an older (or
+// differently-behaved) flowcli binary is treated as a miss instead of
+// being reused as-is -- otherwise a directory whose files haven't
+// changed would keep serving results computed under the old rules until
+// some unrelated edit happened to invalidate the hash.
+const schemaVersion = 1
+
+// Entry is the cached extraction result for a directory, together with
+// the file hash it was computed from and the schemaVersion it was
+// produced by.
+type Entry struct {
+	Version int             `json:"version"`
+	Hash    string          `json:"hash"`
+	Package codegen.Package `json:"package"`
+}
+
+// fileStamp is one file's staleness signature: path, size and
+// modification time, without reading its contents.
+type fileStamp struct {
+	Name    string `json:"name"`
+	Size    int64  `json:"size"`
+	ModTime int64  `json:"mod_time"`
+}
+
+// HashDir returns a deterministic hash of the .go files directly inside
+// dir. It does not descend into subdirectories: a subdirectory with its
+// own go.mod is a separate module and is never walked into, and a
+// symlinked entry is rejected outright since following it could make
+// the hash depend on state outside dir, breaking reproducibility.
+func HashDir(dir string) (string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", fmt.Errorf("reading directory %s: %w", dir, err)
+	}
+
+	var stamps []fileStamp
+	for _, entry := range entries {
+		if entry.Type()&fs.ModeSymlink != 0 {
+			return "", fmt.Errorf("refusing to index %s: %s is a symlink", dir, entry.Name())
+		}
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".go" {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return "", fmt.Errorf("stat %s: %w", entry.Name(), err)
+		}
+		stamps = append(stamps, fileStamp{
+			Name:    entry.Name(),
+			Size:    info.Size(),
+			ModTime: info.ModTime().UnixNano(),
+		})
+	}
+	sort.Slice(stamps, func(i, j int) bool { return stamps[i].Name < stamps[j].Name })
+
+	data, err := json.Marshal(stamps)
+	if err != nil {
+		return "", fmt.Errorf("marshaling file stamps for %s: %w", dir, err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// ModulePath returns the module path declared by the nearest go.mod
+// found by walking up from dir, the same way the go command resolves a
+// directory's enclosing module.
+func ModulePath(dir string) (string, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", fmt.Errorf("resolving %s: %w", dir, err)
+	}
+
+	for d := abs; ; {
+		modPath := filepath.Join(d, "go.mod")
+		data, err := os.ReadFile(modPath)
+		if err == nil {
+			mf, err := modfile.Parse(modPath, data, nil)
+			if err != nil {
+				return "", fmt.Errorf("parsing %s: %w", modPath, err)
+			}
+			return mf.Module.Mod.Path, nil
+		}
+		if !os.IsNotExist(err) {
+			return "", fmt.Errorf("reading %s: %w", modPath, err)
+		}
+
+		parent := filepath.Dir(d)
+		if parent == d {
+			return "", fmt.Errorf("no go.mod found above %s", dir)
+		}
+		d = parent
+	}
+}
+
+// Dir returns the on-disk directory flowcli stores its index files in,
+// creating it if necessary.
+func Dir() (string, error) {
+	base, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("resolving user cache dir: %w", err)
+	}
+	dir := filepath.Join(base, "flowcli")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("creating cache dir %s: %w", dir, err)
+	}
+	return dir, nil
+}
+
+// indexPath returns the cache file for dir within modulePath, keyed by
+// both so that two modules with a directory of the same name (e.g. two
+// checkouts each with a "handlers" dir) don't collide.
+func indexPath(modulePath, dir string) (string, error) {
+	cacheDir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", fmt.Errorf("resolving %s: %w", dir, err)
+	}
+	key := sha256.Sum256([]byte(modulePath + "|" + abs))
+	return filepath.Join(cacheDir, hex.EncodeToString(key[:])+".json"), nil
+}
+
+// Load returns the cached Entry for dir within modulePath, or nil if none
+// exists yet or it was written by a different schemaVersion. Callers must
+// still compare the returned Entry's Hash against a fresh HashDir
+// themselves to decide if it's valid; a version mismatch is reported the
+// same way as no cache at all, since both mean the same thing to a
+// caller: re-extract.
+func Load(modulePath, dir string) (*Entry, error) {
+	path, err := indexPath(modulePath, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("reading cache index %s: %w", path, err)
+	}
+
+	var entry Entry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, fmt.Errorf("parsing cache index %s: %w", path, err)
+	}
+	if entry.Version != schemaVersion {
+		return nil, nil
+	}
+	return &entry, nil
+}
+
+// Save writes entry as the cached result for dir within modulePath,
+// stamping it with the current schemaVersion regardless of what the
+// caller set.
+func Save(modulePath, dir string, entry Entry) error {
+	path, err := indexPath(modulePath, dir)
+	if err != nil {
+		return err
+	}
+
+	entry.Version = schemaVersion
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshaling cache index: %w", err)
+	}
+	return os.WriteFile(path, data, 0o644)
+}
@@ -0,0 +1,209 @@
+// Package codegen renders the generated FuncMap file that wires handler
+// functions into flow.ProcessHandler entries. It replaces flowcli's
+// previous strings.Builder/fmt.Sprintf assembly with a text/template
+// rendered through go/format and golang.org/x/tools/imports, so the
+// output is always gofmt-clean and its imports are resolved rather than
+// hardcoded.
+package codegen
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"sort"
+	"text/template"
+
+	"golang.org/x/tools/imports"
+)
+
+// Handler is a single function to register in the generated FuncMap.
+type Handler struct {
+	// Name is the function identifier in its Package, and also the
+	// FuncMap key when Key is empty.
+	Name string
+	// Key overrides the FuncMap key this handler registers under. It's
+	// set from a //flow:handler marker's name="..." attribute; empty
+	// means Name is used instead.
+	Key string
+	// Inputs optionally lists the input names a //flow:handler marker
+	// declared for this handler. It plays no part in rendering; it's
+	// carried through for callers that want to inspect a handler's
+	// declared inputs.
+	Inputs []string
+	// Meta holds any other key="value" attributes a //flow:handler
+	// marker declared, beyond name and inputs.
+	Meta map[string]string
+}
+
+// Package groups the handlers found in one source package, keyed by its
+// import path.
+type Package struct {
+	// Name is the package's identifier, used to qualify handler
+	// references (e.g. "handlers" in "handlers.MyFunc"). It comes from
+	// go/packages' resolved package name, not the directory's basename,
+	// so it's correct even when the directory and package names differ.
+	Name string
+	// ImportPath is the fully qualified import path to add to the
+	// generated file's import block.
+	ImportPath string
+	// Dir is the package's directory on disk. It plays no part in
+	// rendering; callers use it to match a package back to the
+	// directory pattern that produced it (e.g. for cache bookkeeping).
+	Dir      string
+	Handlers []Handler
+}
+
+// Options controls how Render keys the generated FuncMap.
+type Options struct {
+	// Namespace prefixes every FuncMap key with its package name (e.g.
+	// "foo.Handler" instead of bare "Handler"), so that handlers of the
+	// same name in different packages don't collide.
+	Namespace bool
+}
+
+// renderPackage is a Package plus the import alias Render assigned it,
+// if any, and the namespacing option Render was called with.
+type renderPackage struct {
+	Package
+	Alias     string // empty if the package needs no import alias
+	namespace bool
+}
+
+// qualifier is the identifier renderPackage.Handlers are referenced
+// through in the generated source: the import alias if one was
+// assigned, otherwise the package's own name.
+func (p renderPackage) qualifier() string {
+	if p.Alias != "" {
+		return p.Alias
+	}
+	return p.Name
+}
+
+// key returns the FuncMap key for h: h.Key if the handler's marker
+// overrode it, otherwise h.Name, namespaced by the package's qualifier
+// when ns is set. The qualifier (rather than the bare package Name) is
+// what actually disambiguates: two packages with the same Name are
+// exactly the case namespacing exists for, and they're told apart by
+// their assigned import alias, not by the name they share.
+func (p renderPackage) key(h Handler, ns bool) string {
+	name := h.Name
+	if h.Key != "" {
+		name = h.Key
+	}
+	if ns {
+		return p.qualifier() + "." + name
+	}
+	return name
+}
+
+var outputTemplate = template.Must(template.New("out.go").Parse(`// Code generated by flowcli. DO NOT EDIT.
+
+package output
+
+import (
+	"fmt"
+
+	"github.com/e4coder/flow"
+{{range .Packages}}	{{if .Alias}}{{.Alias}} {{end}}"{{.ImportPath}}"
+{{end}})
+
+var FuncMap = make(map[string]flow.ProcessHandler)
+
+func Init() {
+{{range .Packages}}{{$pkg := .}}{{range .Handlers}}	FuncMap["{{$pkg.Key .}}"] = {{$pkg.Qualifier}}.{{.Name}}
+{{end}}{{end}}}
+
+func GetFuncByName(name string) (flow.ProcessHandler, error) {
+	fn, ok := FuncMap[name]
+	if !ok {
+		return nil, fmt.Errorf("function %s not found", name)
+	}
+	return fn, nil
+}
+`))
+
+// Key and Qualifier are exported wrappers around renderPackage's
+// unexported helpers, since text/template can only call exported
+// methods.
+func (p renderPackage) Key(h Handler) string { return p.key(h, p.namespace) }
+func (p renderPackage) Qualifier() string    { return p.qualifier() }
+
+// Render renders the out.go source for the given packages: it executes
+// outputTemplate, runs the result through go/format to gofmt it, then
+// through imports.Process to resolve and tidy the import block. filename
+// is used only as a hint to imports.Process and need not exist on disk.
+//
+// Packages whose Name collides with another package's are given
+// sequential import aliases (h1, h2, ...) so the generated file never
+// has two imports bound to the same identifier.
+//
+// Render returns an error if two packages would register the same
+// FuncMap key, since FuncMap keys must be unique; enabling
+// Options.Namespace is usually how a caller resolves that.
+func Render(filename string, packages []Package, opts Options) ([]byte, error) {
+	rendered := alias(packages, opts.Namespace)
+
+	if err := checkDuplicates(rendered, opts.Namespace); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := outputTemplate.Execute(&buf, struct{ Packages []renderPackage }{rendered}); err != nil {
+		return nil, fmt.Errorf("rendering out.go template: %w", err)
+	}
+
+	formatted, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("gofmt on generated out.go: %w", err)
+	}
+
+	processed, err := imports.Process(filename, formatted, nil)
+	if err != nil {
+		return nil, fmt.Errorf("goimports on generated out.go: %w", err)
+	}
+
+	return processed, nil
+}
+
+// alias assigns a sequential "h1", "h2", ... import alias to every
+// package whose Name is shared by another package in the list, so the
+// generated import block never binds two import paths to the same
+// identifier.
+func alias(packages []Package, namespace bool) []renderPackage {
+	byName := make(map[string]int, len(packages))
+	for _, pkg := range packages {
+		byName[pkg.Name]++
+	}
+
+	out := make([]renderPackage, len(packages))
+	n := 0
+	for i, pkg := range packages {
+		rp := renderPackage{Package: pkg, namespace: namespace}
+		if byName[pkg.Name] > 1 {
+			n++
+			rp.Alias = fmt.Sprintf("h%d", n)
+		}
+		out[i] = rp
+	}
+	return out
+}
+
+func checkDuplicates(packages []renderPackage, namespace bool) error {
+	seenIn := make(map[string]string)
+	var dups []string
+	for _, pkg := range packages {
+		for _, h := range pkg.Handlers {
+			key := pkg.key(h, namespace)
+			if first, ok := seenIn[key]; ok {
+				dups = append(dups, fmt.Sprintf("%s (registered by both %s and %s)", key, first, pkg.ImportPath))
+				continue
+			}
+			seenIn[key] = pkg.ImportPath
+		}
+	}
+	if len(dups) == 0 {
+		return nil
+	}
+	sort.Strings(dups)
+	return fmt.Errorf("duplicate FuncMap key(s) across packages: %s (enable namespacing to disambiguate)", dups)
+}
@@ -0,0 +1,133 @@
+package codegen
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAliasAssignsOnlyOnNameCollision(t *testing.T) {
+	packages := []Package{
+		{Name: "handlers", ImportPath: "example.com/app/handlers"},
+		{Name: "handlers", ImportPath: "example.com/app/sub/handlers"},
+		{Name: "other", ImportPath: "example.com/app/other"},
+	}
+
+	rendered := alias(packages, false)
+
+	if rendered[0].Alias != "h1" {
+		t.Errorf("rendered[0].Alias = %q, want %q", rendered[0].Alias, "h1")
+	}
+	if rendered[1].Alias != "h2" {
+		t.Errorf("rendered[1].Alias = %q, want %q", rendered[1].Alias, "h2")
+	}
+	if rendered[2].Alias != "" {
+		t.Errorf("rendered[2].Alias = %q, want no alias for a non-colliding name", rendered[2].Alias)
+	}
+}
+
+func TestAliasPreservesInputOrder(t *testing.T) {
+	packages := []Package{
+		{Name: "handlers", ImportPath: "example.com/app/handlers"},
+		{Name: "handlers", ImportPath: "example.com/app/sub/handlers"},
+	}
+
+	rendered := alias(packages, false)
+
+	if rendered[0].ImportPath != "example.com/app/handlers" || rendered[0].Alias != "h1" {
+		t.Errorf("rendered[0] = %+v, want handlers aliased h1", rendered[0])
+	}
+	if rendered[1].ImportPath != "example.com/app/sub/handlers" || rendered[1].Alias != "h2" {
+		t.Errorf("rendered[1] = %+v, want sub/handlers aliased h2", rendered[1])
+	}
+}
+
+func TestRenderPackageKeyBareName(t *testing.T) {
+	p := renderPackage{Package: Package{Name: "handlers"}}
+	if got := p.key(Handler{Name: "DoThing"}, false); got != "DoThing" {
+		t.Errorf("key() = %q, want %q", got, "DoThing")
+	}
+}
+
+func TestRenderPackageKeyMarkerOverride(t *testing.T) {
+	p := renderPackage{Package: Package{Name: "handlers"}}
+	if got := p.key(Handler{Name: "DoThing", Key: "do-thing"}, false); got != "do-thing" {
+		t.Errorf("key() = %q, want the marker override %q", got, "do-thing")
+	}
+}
+
+func TestRenderPackageKeyNamespacedByName(t *testing.T) {
+	p := renderPackage{Package: Package{Name: "handlers"}}
+	if got := p.key(Handler{Name: "DoThing"}, true); got != "handlers.DoThing" {
+		t.Errorf("key() = %q, want %q", got, "handlers.DoThing")
+	}
+}
+
+func TestRenderPackageKeyNamespacedByAlias(t *testing.T) {
+	p := renderPackage{Package: Package{Name: "handlers"}, Alias: "h2"}
+	if got := p.key(Handler{Name: "DoThing"}, true); got != "h2.DoThing" {
+		t.Errorf("key() = %q, want the alias used over the shared package name %q", got, "h2.DoThing")
+	}
+}
+
+func TestCheckDuplicatesReportsCollision(t *testing.T) {
+	packages := []renderPackage{
+		{Package: Package{Name: "a", ImportPath: "example.com/app/a", Handlers: []Handler{{Name: "Do"}}}},
+		{Package: Package{Name: "b", ImportPath: "example.com/app/b", Handlers: []Handler{{Name: "Do"}}}},
+	}
+
+	err := checkDuplicates(packages, false)
+	if err == nil {
+		t.Fatal("checkDuplicates() = nil, want an error for the shared key \"Do\"")
+	}
+	if !strings.Contains(err.Error(), "example.com/app/a") || !strings.Contains(err.Error(), "example.com/app/b") {
+		t.Errorf("checkDuplicates() error = %q, want it to name both colliding import paths", err)
+	}
+}
+
+func TestCheckDuplicatesNamespaceResolvesCollision(t *testing.T) {
+	packages := []renderPackage{
+		{Package: Package{Name: "a", ImportPath: "example.com/app/a", Handlers: []Handler{{Name: "Do"}}}, namespace: true},
+		{Package: Package{Name: "b", ImportPath: "example.com/app/b", Handlers: []Handler{{Name: "Do"}}}, namespace: true},
+	}
+
+	if err := checkDuplicates(packages, true); err != nil {
+		t.Errorf("checkDuplicates() with namespacing = %v, want nil since a.Do and b.Do are distinct keys", err)
+	}
+}
+
+func TestRenderSinglePackage(t *testing.T) {
+	packages := []Package{
+		{
+			Name:       "handlers",
+			ImportPath: "example.com/app/handlers",
+			Handlers:   []Handler{{Name: "DoThing"}},
+		},
+	}
+
+	out, err := Render("out.go", packages, Options{})
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+
+	got := string(out)
+	for _, want := range []string{
+		`"example.com/app/handlers"`,
+		`FuncMap["DoThing"] = handlers.DoThing`,
+		`func GetFuncByName(name string) (flow.ProcessHandler, error)`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Render() output missing %q\ngot:\n%s", want, got)
+		}
+	}
+}
+
+func TestRenderRejectsDuplicateKeys(t *testing.T) {
+	packages := []Package{
+		{Name: "a", ImportPath: "example.com/app/a", Handlers: []Handler{{Name: "Do"}}},
+		{Name: "b", ImportPath: "example.com/app/b", Handlers: []Handler{{Name: "Do"}}},
+	}
+
+	if _, err := Render("out.go", packages, Options{}); err == nil {
+		t.Fatal("Render() = nil error, want a duplicate-key error")
+	}
+}
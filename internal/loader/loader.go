@@ -0,0 +1,97 @@
+// Package loader resolves Go packages the way the go tool itself does,
+// using golang.org/x/tools/go/packages. It replaces flowcli's previous
+// ad-hoc filepath.Walk + go/parser scanning, so that flowcli can be run
+// from any subdirectory of a module, understands build tags and
+// replace directives, and can see handler functions spread across
+// multiple packages.
+package loader
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"path/filepath"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// mode is the set of information we need from the go/packages driver:
+// enough to resolve import paths and type-check function signatures
+// without re-parsing or re-type-checking anything ourselves.
+const mode = packages.NeedName |
+	packages.NeedFiles |
+	packages.NeedCompiledGoFiles |
+	packages.NeedImports |
+	packages.NeedTypes |
+	packages.NeedTypesInfo |
+	packages.NeedSyntax
+
+// HandlerPackage is a loaded package together with the function
+// declarations found in its syntax trees.
+type HandlerPackage struct {
+	Pkg   *packages.Package
+	Funcs []*ast.FuncDecl
+}
+
+// Loader loads Go packages rooted at a working directory, walking up to
+// the enclosing go.mod the same way the go command does.
+type Loader struct {
+	// Dir is the directory patterns are resolved relative to. If empty,
+	// the current working directory is used.
+	Dir string
+
+	// Fset records source positions for every file read by Load, so
+	// callers can turn an *ast.FuncDecl into a token.Position for
+	// diagnostics. Each call to Load replaces it with a fresh FileSet.
+	Fset *token.FileSet
+}
+
+// New returns a Loader that resolves patterns relative to dir.
+func New(dir string) *Loader {
+	return &Loader{Dir: dir}
+}
+
+// Load resolves patterns (e.g. a directory, "./...", or an import path)
+// into fully type-checked packages. It returns an error if any package
+// failed to load or contains errors, so callers don't silently operate
+// on partial data.
+func (l *Loader) Load(patterns ...string) ([]*HandlerPackage, error) {
+	l.Fset = token.NewFileSet()
+	cfg := &packages.Config{
+		Mode: mode,
+		Dir:  l.Dir,
+		Fset: l.Fset,
+	}
+
+	pkgs, err := packages.Load(cfg, patterns...)
+	if err != nil {
+		return nil, fmt.Errorf("loading packages %v: %w", patterns, err)
+	}
+	if n := packages.PrintErrors(pkgs); n > 0 {
+		return nil, fmt.Errorf("%d error(s) loading packages %v", n, patterns)
+	}
+
+	out := make([]*HandlerPackage, 0, len(pkgs))
+	for _, pkg := range pkgs {
+		hp := &HandlerPackage{Pkg: pkg}
+		for _, file := range pkg.Syntax {
+			for _, decl := range file.Decls {
+				if fd, ok := decl.(*ast.FuncDecl); ok {
+					hp.Funcs = append(hp.Funcs, fd)
+				}
+			}
+		}
+		out = append(out, hp)
+	}
+	return out, nil
+}
+
+// PackageDir returns the directory pkg's source files live in.
+// *packages.Package has no Dir field of its own; it's derived from one
+// of its compiled Go files the same way `go list`'s own Dir output is.
+func PackageDir(pkg *packages.Package) string {
+	if len(pkg.CompiledGoFiles) == 0 {
+		return ""
+	}
+	return filepath.Dir(pkg.CompiledGoFiles[0])
+}
@@ -0,0 +1,69 @@
+package loader
+
+import (
+	"go/ast"
+	"regexp"
+	"strings"
+)
+
+// markerLineRe matches a doc comment line carrying a //flow:handler
+// marker, capturing everything after it as a string of key="value"
+// attributes.
+var markerLineRe = regexp.MustCompile(`^//\s*flow:handler\b(.*)$`)
+
+// markerAttrRe matches one key="value" attribute within a marker line.
+var markerAttrRe = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// Marker is the parsed form of a //flow:handler doc-comment marker.
+type Marker struct {
+	// Name overrides the FuncMap key the handler registers under. Empty
+	// means the function's own name is used.
+	Name string
+	// Inputs optionally lists the input names the marker declared, e.g.
+	// from an inputs="a,b" attribute.
+	Inputs []string
+	// Meta holds any other key="value" attributes on the marker line,
+	// beyond name and inputs.
+	Meta map[string]string
+}
+
+// ParseMarker looks for a "//flow:handler ..." line in doc and parses its
+// key="value" attributes. It reports false if doc has no such marker, so
+// callers can tell "no marker" apart from "marker with no attributes".
+//
+// Recognizing an explicit marker rather than auto-registering every
+// function with a matching signature means a package can define helper
+// functions that happen to share a handler's shape without exporting them
+// to FuncMap by accident.
+func ParseMarker(doc *ast.CommentGroup) (*Marker, bool) {
+	if doc == nil {
+		return nil, false
+	}
+
+	for _, c := range doc.List {
+		m := markerLineRe.FindStringSubmatch(c.Text)
+		if m == nil {
+			continue
+		}
+
+		marker := &Marker{}
+		for _, attr := range markerAttrRe.FindAllStringSubmatch(m[1], -1) {
+			key, value := attr[1], attr[2]
+			switch key {
+			case "name":
+				marker.Name = value
+			case "inputs":
+				if value != "" {
+					marker.Inputs = strings.Split(value, ",")
+				}
+			default:
+				if marker.Meta == nil {
+					marker.Meta = make(map[string]string)
+				}
+				marker.Meta[key] = value
+			}
+		}
+		return marker, true
+	}
+	return nil, false
+}
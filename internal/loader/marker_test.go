@@ -0,0 +1,131 @@
+package loader
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"testing"
+)
+
+// parseDoc parses src (a single function declaration with a leading doc
+// comment) and returns that function's *ast.CommentGroup.
+func parseDoc(t *testing.T, src string) *ast.CommentGroup {
+	t.Helper()
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "handlers.go", "package handlers\n\n"+src, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("parsing fixture: %v", err)
+	}
+	for _, decl := range file.Decls {
+		if fd, ok := decl.(*ast.FuncDecl); ok {
+			return fd.Doc
+		}
+	}
+	t.Fatal("fixture has no function declaration")
+	return nil
+}
+
+func TestParseMarkerNoMarker(t *testing.T) {
+	doc := parseDoc(t, `
+// Helper is just a regular doc comment, no marker.
+func Helper() {}
+`)
+	if _, ok := ParseMarker(doc); ok {
+		t.Fatal("ParseMarker found a marker where there was none")
+	}
+}
+
+func TestParseMarkerNoDoc(t *testing.T) {
+	if _, ok := ParseMarker(nil); ok {
+		t.Fatal("ParseMarker(nil) reported a marker")
+	}
+}
+
+func TestParseMarkerBare(t *testing.T) {
+	doc := parseDoc(t, `
+//flow:handler
+func Step() {}
+`)
+	m, ok := ParseMarker(doc)
+	if !ok {
+		t.Fatal("ParseMarker did not find the marker")
+	}
+	if m.Name != "" || m.Inputs != nil || m.Meta != nil {
+		t.Errorf("ParseMarker(bare marker) = %+v, want all fields zero", m)
+	}
+}
+
+func TestParseMarkerNameOverride(t *testing.T) {
+	doc := parseDoc(t, `
+//flow:handler name="my-step"
+func Step() {}
+`)
+	m, ok := ParseMarker(doc)
+	if !ok {
+		t.Fatal("ParseMarker did not find the marker")
+	}
+	if m.Name != "my-step" {
+		t.Errorf("m.Name = %q, want %q", m.Name, "my-step")
+	}
+}
+
+func TestParseMarkerInputs(t *testing.T) {
+	doc := parseDoc(t, `
+//flow:handler name="my-step" inputs="a,b,c"
+func Step() {}
+`)
+	m, ok := ParseMarker(doc)
+	if !ok {
+		t.Fatal("ParseMarker did not find the marker")
+	}
+	want := []string{"a", "b", "c"}
+	if !reflect.DeepEqual(m.Inputs, want) {
+		t.Errorf("m.Inputs = %v, want %v", m.Inputs, want)
+	}
+}
+
+func TestParseMarkerEmptyInputsIsNil(t *testing.T) {
+	doc := parseDoc(t, `
+//flow:handler inputs=""
+func Step() {}
+`)
+	m, ok := ParseMarker(doc)
+	if !ok {
+		t.Fatal("ParseMarker did not find the marker")
+	}
+	if m.Inputs != nil {
+		t.Errorf("m.Inputs = %v, want nil for an empty inputs attribute", m.Inputs)
+	}
+}
+
+func TestParseMarkerMeta(t *testing.T) {
+	doc := parseDoc(t, `
+//flow:handler name="my-step" version="2" owner="team-x"
+func Step() {}
+`)
+	m, ok := ParseMarker(doc)
+	if !ok {
+		t.Fatal("ParseMarker did not find the marker")
+	}
+	want := map[string]string{"version": "2", "owner": "team-x"}
+	if !reflect.DeepEqual(m.Meta, want) {
+		t.Errorf("m.Meta = %v, want %v", m.Meta, want)
+	}
+}
+
+func TestParseMarkerAmongOtherDocLines(t *testing.T) {
+	doc := parseDoc(t, `
+// Step does something.
+//flow:handler name="my-step"
+// It has more doc below the marker too.
+func Step() {}
+`)
+	m, ok := ParseMarker(doc)
+	if !ok {
+		t.Fatal("ParseMarker did not find the marker among other doc comment lines")
+	}
+	if m.Name != "my-step" {
+		t.Errorf("m.Name = %q, want %q", m.Name, "my-step")
+	}
+}
@@ -0,0 +1,123 @@
+package loader
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// flowImportPath is the package whose ProcessContext and DefinedInput
+// types a handler must use.
+const flowImportPath = "github.com/e4coder/flow"
+
+// FlowTypes holds the resolved *flow.ProcessContext and []flow.DefinedInput
+// types that a valid handler's parameters must match.
+type FlowTypes struct {
+	ProcessContext types.Type
+	DefinedInputs  types.Type
+}
+
+// ResolveFlowTypes looks up ProcessContext and DefinedInput through pkg's
+// own import of flowImportPath, so it keys off the actual *types.Package
+// the handler code was compiled against rather than a hardcoded string.
+// This means a renamed import (`f "github.com/e4coder/flow"`), a dot
+// import, or a vendored copy are all handled the same way.
+func ResolveFlowTypes(pkg *packages.Package) (*FlowTypes, error) {
+	flowPkg, ok := pkg.Imports[flowImportPath]
+	if !ok {
+		return nil, fmt.Errorf("package %s does not import %s", pkg.PkgPath, flowImportPath)
+	}
+
+	scope := flowPkg.Types.Scope()
+
+	processContext := scope.Lookup("ProcessContext")
+	if processContext == nil {
+		return nil, fmt.Errorf("%s: type ProcessContext not found", flowImportPath)
+	}
+	definedInput := scope.Lookup("DefinedInput")
+	if definedInput == nil {
+		return nil, fmt.Errorf("%s: type DefinedInput not found", flowImportPath)
+	}
+
+	return &FlowTypes{
+		ProcessContext: types.NewPointer(processContext.Type()),
+		DefinedInputs:  types.NewSlice(definedInput.Type()),
+	}, nil
+}
+
+// Diagnostic explains why a candidate function was rejected as a
+// handler.
+type Diagnostic struct {
+	Pos    token.Position
+	Func   string
+	Reason string
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s: func %s: %s", d.Pos, d.Func, d.Reason)
+}
+
+// IsHandler reports whether funcDecl has the signature
+// func(*flow.ProcessContext, []flow.DefinedInput) error, resolved via
+// go/types rather than matching the AST against hardcoded strings.
+//
+// If funcDecl does not resolve to a plain function (not a candidate at all, e.g. a
+// method or a builtin) IsHandler reports a nil Diagnostic. Otherwise it
+// also returns a Diagnostic explaining why it failed to qualify (wrong
+// arity, wrong parameter type, wrong return type, unexported, generic, or
+// variadic), so a near-miss handler produces a clear error instead of
+// being silently dropped from FuncMap.
+func IsHandler(pkg *packages.Package, funcDecl *ast.FuncDecl, ft *FlowTypes, fset *token.FileSet) (bool, *Diagnostic) {
+	obj, ok := pkg.TypesInfo.Defs[funcDecl.Name]
+	if !ok || obj == nil {
+		return false, nil
+	}
+	fn, ok := obj.(*types.Func)
+	if !ok {
+		return false, nil
+	}
+	sig, ok := fn.Type().(*types.Signature)
+	if !ok {
+		return false, nil
+	}
+	if sig.Recv() != nil {
+		return false, nil
+	}
+
+	pos := fset.Position(funcDecl.Pos())
+	fail := func(reason string) (bool, *Diagnostic) {
+		return false, &Diagnostic{Pos: pos, Func: funcDecl.Name.Name, Reason: reason}
+	}
+
+	if sig.Params().Len() != 2 {
+		return fail(fmt.Sprintf("handler must take exactly 2 parameters, got %d", sig.Params().Len()))
+	}
+	if !funcDecl.Name.IsExported() {
+		return fail("handler must be exported")
+	}
+	if sig.Variadic() {
+		return fail("handler must not be variadic")
+	}
+	if sig.TypeParams().Len() > 0 {
+		return fail("handler must not be generic")
+	}
+
+	params := sig.Params()
+	if !types.Identical(params.At(0).Type(), ft.ProcessContext) {
+		return fail(fmt.Sprintf("first parameter must be *flow.ProcessContext, got %s", params.At(0).Type()))
+	}
+	if !types.Identical(params.At(1).Type(), ft.DefinedInputs) {
+		return fail(fmt.Sprintf("second parameter must be []flow.DefinedInput, got %s", params.At(1).Type()))
+	}
+
+	errType := types.Universe.Lookup("error").Type()
+	results := sig.Results()
+	if results.Len() != 1 || !types.Identical(results.At(0).Type(), errType) {
+		return fail("handler must return a single error")
+	}
+
+	return true, nil
+}
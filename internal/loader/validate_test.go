@@ -0,0 +1,224 @@
+package loader
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"testing"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// flowFixture is a standalone stand-in for github.com/e4coder/flow, type
+// checked on its own so tests don't depend on the real module being
+// resolvable.
+const flowFixture = `
+package flow
+
+type ProcessContext struct{}
+type DefinedInput struct{}
+`
+
+type importerFunc func(path string) (*types.Package, error)
+
+func (f importerFunc) Import(path string) (*types.Package, error) { return f(path) }
+
+// checkFixture type-checks src as a package that imports
+// "github.com/e4coder/flow" (backed by flowFixture, not the real module)
+// and returns a *packages.Package shaped the way the real go/packages
+// driver would produce one, along with its parsed function declarations.
+// This lets IsHandler and ResolveFlowTypes be exercised without actually
+// invoking the go/packages driver.
+func checkFixture(t *testing.T, src string) (*packages.Package, []*ast.FuncDecl, *token.FileSet) {
+	t.Helper()
+
+	flowFset := token.NewFileSet()
+	flowFile, err := parser.ParseFile(flowFset, "flow.go", flowFixture, 0)
+	if err != nil {
+		t.Fatalf("parsing flow fixture: %v", err)
+	}
+	flowPkg := types.NewPackage(flowImportPath, "flow")
+	if err := types.NewChecker(&types.Config{}, flowFset, flowPkg, nil).Files([]*ast.File{flowFile}); err != nil {
+		t.Fatalf("type-checking flow fixture: %v", err)
+	}
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "handlers.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("parsing test fixture: %v", err)
+	}
+
+	info := &types.Info{Defs: make(map[*ast.Ident]types.Object)}
+	conf := types.Config{
+		Importer: importerFunc(func(path string) (*types.Package, error) {
+			if path == flowImportPath {
+				return flowPkg, nil
+			}
+			return nil, fmt.Errorf("unexpected import %q", path)
+		}),
+	}
+	testPkg, err := conf.Check("testpkg", fset, []*ast.File{file}, info)
+	if err != nil {
+		t.Fatalf("type-checking test fixture: %v", err)
+	}
+
+	pkg := &packages.Package{
+		PkgPath:   "testpkg",
+		Types:     testPkg,
+		TypesInfo: info,
+		Imports:   map[string]*packages.Package{flowImportPath: {Types: flowPkg}},
+		Syntax:    []*ast.File{file},
+	}
+
+	var funcs []*ast.FuncDecl
+	for _, decl := range file.Decls {
+		if fd, ok := decl.(*ast.FuncDecl); ok {
+			funcs = append(funcs, fd)
+		}
+	}
+	return pkg, funcs, fset
+}
+
+func funcByName(funcs []*ast.FuncDecl, name string) *ast.FuncDecl {
+	for _, fd := range funcs {
+		if fd.Name.Name == name {
+			return fd
+		}
+	}
+	return nil
+}
+
+func TestIsHandlerAcceptsValidSignature(t *testing.T) {
+	pkg, funcs, fset := checkFixture(t, `
+package handlers
+
+import "github.com/e4coder/flow"
+
+func Valid(pc *flow.ProcessContext, in []flow.DefinedInput) error { return nil }
+`)
+	ft, err := ResolveFlowTypes(pkg)
+	if err != nil {
+		t.Fatalf("ResolveFlowTypes: %v", err)
+	}
+
+	ok, diag := IsHandler(pkg, funcByName(funcs, "Valid"), ft, fset)
+	if !ok || diag != nil {
+		t.Fatalf("IsHandler(Valid) = (%v, %v), want (true, nil)", ok, diag)
+	}
+}
+
+func TestIsHandlerReportsWrongArity(t *testing.T) {
+	pkg, funcs, fset := checkFixture(t, `
+package handlers
+
+import "github.com/e4coder/flow"
+
+func BadArity(pc *flow.ProcessContext) error { return nil }
+`)
+	ft, err := ResolveFlowTypes(pkg)
+	if err != nil {
+		t.Fatalf("ResolveFlowTypes: %v", err)
+	}
+
+	ok, diag := IsHandler(pkg, funcByName(funcs, "BadArity"), ft, fset)
+	if ok {
+		t.Fatal("IsHandler(BadArity) = true, want false")
+	}
+	if diag == nil {
+		t.Fatal("IsHandler(BadArity) diagnostic = nil, want a diagnostic explaining the arity mismatch")
+	}
+	const want = "handler must take exactly 2 parameters, got 1"
+	if diag.Reason != want {
+		t.Errorf("diagnostic reason = %q, want %q", diag.Reason, want)
+	}
+}
+
+func TestIsHandlerReportsUnexported(t *testing.T) {
+	pkg, funcs, fset := checkFixture(t, `
+package handlers
+
+import "github.com/e4coder/flow"
+
+func unexported(pc *flow.ProcessContext, in []flow.DefinedInput) error { return nil }
+`)
+	ft, err := ResolveFlowTypes(pkg)
+	if err != nil {
+		t.Fatalf("ResolveFlowTypes: %v", err)
+	}
+
+	ok, diag := IsHandler(pkg, funcByName(funcs, "unexported"), ft, fset)
+	if ok || diag == nil {
+		t.Fatalf("IsHandler(unexported) = (%v, %v), want (false, non-nil)", ok, diag)
+	}
+	if diag.Reason != "handler must be exported" {
+		t.Errorf("diagnostic reason = %q, want %q", diag.Reason, "handler must be exported")
+	}
+}
+
+func TestIsHandlerReportsWrongArityWithExtraParam(t *testing.T) {
+	pkg, funcs, fset := checkFixture(t, `
+package handlers
+
+import "github.com/e4coder/flow"
+
+func ThreeParams(pc *flow.ProcessContext, in []flow.DefinedInput, extra int) error { return nil }
+`)
+	ft, err := ResolveFlowTypes(pkg)
+	if err != nil {
+		t.Fatalf("ResolveFlowTypes: %v", err)
+	}
+
+	ok, diag := IsHandler(pkg, funcByName(funcs, "ThreeParams"), ft, fset)
+	if ok || diag == nil {
+		t.Fatalf("IsHandler(ThreeParams) = (%v, %v), want (false, non-nil)", ok, diag)
+	}
+}
+
+func TestIsHandlerReportsVariadic(t *testing.T) {
+	pkg, funcs, fset := checkFixture(t, `
+package handlers
+
+import "github.com/e4coder/flow"
+
+func Variadic(pc *flow.ProcessContext, in ...flow.DefinedInput) error { return nil }
+`)
+	ft, err := ResolveFlowTypes(pkg)
+	if err != nil {
+		t.Fatalf("ResolveFlowTypes: %v", err)
+	}
+
+	// in ...flow.DefinedInput has the same parameter type as
+	// []flow.DefinedInput, so this passes the arity and type checks and
+	// is rejected specifically for being variadic.
+	ok, diag := IsHandler(pkg, funcByName(funcs, "Variadic"), ft, fset)
+	if ok || diag == nil {
+		t.Fatalf("IsHandler(Variadic) = (%v, %v), want (false, non-nil)", ok, diag)
+	}
+	if diag.Reason != "handler must not be variadic" {
+		t.Errorf("diagnostic reason = %q, want %q", diag.Reason, "handler must not be variadic")
+	}
+}
+
+func TestIsHandlerReportsWrongReturnType(t *testing.T) {
+	pkg, funcs, fset := checkFixture(t, `
+package handlers
+
+import "github.com/e4coder/flow"
+
+func BadReturn(pc *flow.ProcessContext, in []flow.DefinedInput) {}
+`)
+	ft, err := ResolveFlowTypes(pkg)
+	if err != nil {
+		t.Fatalf("ResolveFlowTypes: %v", err)
+	}
+
+	ok, diag := IsHandler(pkg, funcByName(funcs, "BadReturn"), ft, fset)
+	if ok || diag == nil {
+		t.Fatalf("IsHandler(BadReturn) = (%v, %v), want (false, non-nil)", ok, diag)
+	}
+	if diag.Reason != "handler must return a single error" {
+		t.Errorf("diagnostic reason = %q, want %q", diag.Reason, "handler must return a single error")
+	}
+}